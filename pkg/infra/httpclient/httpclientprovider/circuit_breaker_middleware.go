@@ -0,0 +1,325 @@
+package httpclientprovider
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/metrics/metricutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// circuitState is one of the three states of the per-datasource circuit
+// breaker. The numeric value is also what's reported on the
+// grafana_httpclient_circuit_state gauge.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// Defaults applied when a datasource sets cb_enabled=true without tuning the
+// rest of the knobs itself.
+const (
+	defaultFailureThreshold    = 0.5
+	defaultMinRequests         = 10
+	defaultOpenDuration        = 30 * time.Second
+	defaultHalfOpenMaxRequests = 1
+	maxCircuitBackoff          = 5 * time.Minute
+)
+
+// errCircuitOpen is returned in place of round-tripping a request while a
+// datasource's circuit breaker is open.
+var errCircuitOpen = errors.New("httpclientprovider: circuit breaker open, short-circuiting request")
+
+var cbLogger = log.New("httpclient.circuitbreaker")
+
+var (
+	circuitBreakerState = promauto.With(prometheus.DefaultRegisterer).NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Subsystem: "httpclient",
+		Name:      "circuit_state",
+		Help:      "Current circuit breaker state per datasource (0=closed, 1=open, 2=half-open).",
+	}, []string{"datasource"})
+
+	circuitBreakerTrips = promauto.With(prometheus.DefaultRegisterer).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Subsystem: "httpclient",
+		Name:      "circuit_trips_total",
+		Help:      "Total number of times a datasource's circuit breaker tripped open.",
+	}, []string{"datasource"})
+)
+
+// CircuitBreakerMiddleware short-circuits requests to a datasource once its
+// rolling failure ratio crosses cb_failure_threshold, giving a dead backend
+// time to recover instead of letting it saturate Grafana's connection pool.
+// It's opt-in per datasource via opts.Labels["cb_enabled"].
+func CircuitBreakerMiddleware() httpclient.Middleware {
+	return httpclient.NamedMiddlewareFunc("circuit-breaker", func(opts httpclient.Options, next http.RoundTripper) http.RoundTripper {
+		if enabled, exists := opts.Labels["cb_enabled"]; !exists || enabled != "true" {
+			return next
+		}
+
+		cb := getOrCreateCircuitBreaker(opts.Labels["datasource_uid"], opts.Labels)
+
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !cb.allow() {
+				return nil, errCircuitOpen
+			}
+
+			resp, err := next.RoundTrip(req)
+			cb.recordResult(err == nil && resp != nil && resp.StatusCode < http.StatusInternalServerError)
+			return resp, err
+		})
+	})
+}
+
+// roundTripperFunc adapts an ordinary function to the http.RoundTripper
+// interface, the same way http.HandlerFunc adapts to http.Handler.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var (
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   = map[string]*circuitBreaker{}
+)
+
+// circuitBreakerConfigLabels are the labels that determine a circuit
+// breaker's tunable config (as opposed to its window state). They're
+// fingerprinted on every getOrCreateCircuitBreaker call so retuning
+// cb_failure_threshold and friends on an existing datasource - a normal,
+// no-restart config edit - rebuilds the breaker instead of being silently
+// ignored until Grafana restarts.
+var circuitBreakerConfigLabels = []string{
+	"cb_failure_threshold",
+	"cb_min_requests",
+	"cb_open_duration",
+	"cb_half_open_max_requests",
+}
+
+// circuitBreakerConfigFingerprint summarizes the circuit-breaker-relevant
+// labels so two label sets can be compared cheaply without re-parsing them.
+func circuitBreakerConfigFingerprint(labels map[string]string) string {
+	var b strings.Builder
+	for _, key := range circuitBreakerConfigLabels {
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(labels[key])
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// getOrCreateCircuitBreaker returns the circuit breaker for datasourceUID,
+// creating or rebuilding one from labels whenever its config labels differ
+// from what's cached. Breakers otherwise live for the lifetime of the
+// process, same as the conntrack dialers they sit next to.
+//
+// Callers without a datasourceUID - non-datasource HTTP clients reusing this
+// provider - never get a cached instance; sharing one keyed on "" would let
+// failures on one unrelated target trip the breaker for everyone else.
+func getOrCreateCircuitBreaker(datasourceUID string, labels map[string]string) *circuitBreaker {
+	if datasourceUID == "" {
+		return newCircuitBreaker("unknown", labels)
+	}
+
+	fingerprint := circuitBreakerConfigFingerprint(labels)
+
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+
+	if cb, exists := circuitBreakers[datasourceUID]; exists && cb.configFingerprint == fingerprint {
+		return cb
+	}
+
+	datasourceLabel, err := metricutil.SanitizeLabelName(datasourceUID)
+	if err != nil {
+		datasourceLabel = "unknown"
+	}
+
+	cb := newCircuitBreaker(datasourceLabel, labels)
+	cb.configFingerprint = fingerprint
+	circuitBreakers[datasourceUID] = cb
+	return cb
+}
+
+// ResetCircuitBreakers discards every cached circuit breaker, so the next
+// request per datasource rebuilds one from its current labels. Call it from
+// Provider.ReloadDefaults so a SIGHUP-driven config reload can retune
+// breakers without waiting for a process restart.
+func ResetCircuitBreakers() {
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+	circuitBreakers = map[string]*circuitBreaker{}
+}
+
+// circuitBreaker is a sliding-window, three-state failure detector. Closed
+// tracks outcomes in a ring buffer holding the last minRequests requests, so
+// the failure ratio reflects only recent traffic instead of accumulating
+// over the process's whole lifetime; crossing failureThreshold trips it open
+// for openDuration, after which a limited number of half-open probes decide
+// whether to close it again or reopen with exponential backoff up to
+// maxCircuitBackoff.
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	failureThreshold    float64
+	minRequests         int
+	openDuration        time.Duration
+	halfOpenMaxRequests int
+	datasourceLabel     string
+	configFingerprint   string // set by getOrCreateCircuitBreaker, compared to detect a label retune
+
+	state            circuitState
+	window           []bool // ring buffer of recent outcomes, true=success
+	windowPos        int
+	windowLen        int // number of valid entries in window so far
+	failures         int // failures currently represented in window
+	openedAt         time.Time
+	backoff          time.Duration
+	halfOpenInFlight int
+}
+
+func newCircuitBreaker(datasourceLabel string, labels map[string]string) *circuitBreaker {
+	openDuration := durationLabel(cbLogger, datasourceLabel, labels, "cb_open_duration", defaultOpenDuration)
+	minRequests := intLabel(cbLogger, datasourceLabel, labels, "cb_min_requests", defaultMinRequests)
+	if minRequests < 1 {
+		minRequests = 1
+	}
+
+	cb := &circuitBreaker{
+		failureThreshold:    floatLabel(datasourceLabel, labels, "cb_failure_threshold", defaultFailureThreshold),
+		minRequests:         minRequests,
+		openDuration:        openDuration,
+		halfOpenMaxRequests: intLabel(cbLogger, datasourceLabel, labels, "cb_half_open_max_requests", defaultHalfOpenMaxRequests),
+		backoff:             openDuration,
+		datasourceLabel:     datasourceLabel,
+		window:              make([]bool, minRequests),
+	}
+	circuitBreakerState.WithLabelValues(datasourceLabel).Set(float64(circuitClosed))
+	return cb
+}
+
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.backoff {
+			return false
+		}
+		cb.transitionTo(circuitHalfOpen)
+		cb.halfOpenInFlight = 1
+		return true
+	case circuitHalfOpen:
+		if cb.halfOpenInFlight >= cb.halfOpenMaxRequests {
+			return false
+		}
+		cb.halfOpenInFlight++
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *circuitBreaker) recordResult(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		cb.halfOpenInFlight--
+		if !success {
+			cb.trip()
+			return
+		}
+		if cb.halfOpenInFlight <= 0 {
+			cb.reset()
+		}
+		return
+	}
+
+	if cb.state == circuitOpen {
+		return
+	}
+
+	cb.recordInWindow(success)
+
+	if cb.windowLen >= cb.minRequests && float64(cb.failures)/float64(cb.windowLen) >= cb.failureThreshold {
+		cb.trip()
+	}
+}
+
+// recordInWindow pushes success into the ring buffer, evicting the oldest
+// entry once it's full so cb.failures always reflects only the last
+// len(cb.window) outcomes.
+func (cb *circuitBreaker) recordInWindow(success bool) {
+	if cb.windowLen == len(cb.window) {
+		if !cb.window[cb.windowPos] {
+			cb.failures--
+		}
+	} else {
+		cb.windowLen++
+	}
+
+	cb.window[cb.windowPos] = success
+	if !success {
+		cb.failures++
+	}
+
+	cb.windowPos = (cb.windowPos + 1) % len(cb.window)
+}
+
+func (cb *circuitBreaker) trip() {
+	if cb.state == circuitOpen || cb.state == circuitHalfOpen {
+		cb.backoff *= 2
+		if cb.backoff > maxCircuitBackoff {
+			cb.backoff = maxCircuitBackoff
+		}
+	}
+
+	cb.transitionTo(circuitOpen)
+	cb.openedAt = time.Now()
+	cb.halfOpenInFlight = 0
+	circuitBreakerTrips.WithLabelValues(cb.datasourceLabel).Inc()
+}
+
+func (cb *circuitBreaker) reset() {
+	cb.transitionTo(circuitClosed)
+	cb.windowPos = 0
+	cb.windowLen = 0
+	cb.failures = 0
+	cb.backoff = cb.openDuration
+	cb.halfOpenInFlight = 0
+}
+
+func (cb *circuitBreaker) transitionTo(state circuitState) {
+	cb.state = state
+	circuitBreakerState.WithLabelValues(cb.datasourceLabel).Set(float64(state))
+}
+
+func floatLabel(datasourceName string, labels map[string]string, key string, fallback float64) float64 {
+	value, exists := labels[key]
+	if !exists {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		cbLogger.Warn("Failed to parse float label, using default", "datasource", datasourceName, "label", key, "value", value, "error", err)
+		return fallback
+	}
+
+	return f
+}
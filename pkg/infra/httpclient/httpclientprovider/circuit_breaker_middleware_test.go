@@ -0,0 +1,127 @@
+package httpclientprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCircuitBreaker(labels map[string]string) *circuitBreaker {
+	return newCircuitBreaker("test", labels)
+}
+
+func TestCircuitBreaker_StaysClosedBelowThreshold(t *testing.T) {
+	cb := newTestCircuitBreaker(map[string]string{
+		"cb_min_requests":      "4",
+		"cb_failure_threshold": "0.5",
+	})
+
+	cb.recordResult(false)
+	cb.recordResult(true)
+	cb.recordResult(true)
+	cb.recordResult(true)
+
+	require.True(t, cb.allow())
+	require.Equal(t, circuitClosed, cb.state)
+}
+
+func TestCircuitBreaker_TripsOnceWindowFillsWithFailures(t *testing.T) {
+	cb := newTestCircuitBreaker(map[string]string{
+		"cb_min_requests":      "5",
+		"cb_failure_threshold": "0.5",
+	})
+
+	cb.recordResult(false)
+	cb.recordResult(false)
+	cb.recordResult(true)
+	cb.recordResult(true)
+	require.Equal(t, circuitClosed, cb.state, "exactly at threshold with fewer than min_requests observed shouldn't trip")
+
+	cb.recordResult(false)
+	require.Equal(t, circuitOpen, cb.state)
+	require.False(t, cb.allow())
+}
+
+func TestCircuitBreaker_WindowIsBoundedNotLifetime(t *testing.T) {
+	cb := newTestCircuitBreaker(map[string]string{
+		"cb_min_requests":      "3",
+		"cb_failure_threshold": "0.5",
+	})
+
+	// A long history of successes should age out of the window instead of
+	// diluting a fresh run of failures forever.
+	for i := 0; i < 1000; i++ {
+		cb.recordResult(true)
+	}
+
+	cb.recordResult(false)
+	cb.recordResult(false)
+	require.Equal(t, circuitOpen, cb.state, "recent failures should trip the breaker regardless of historical successes")
+}
+
+func TestCircuitBreaker_HalfOpenClosesAfterSuccessfulProbes(t *testing.T) {
+	cb := newTestCircuitBreaker(map[string]string{
+		"cb_min_requests":           "2",
+		"cb_failure_threshold":      "0.5",
+		"cb_open_duration":          "1ms",
+		"cb_half_open_max_requests": "1",
+	})
+
+	cb.recordResult(false)
+	cb.recordResult(false)
+	require.Equal(t, circuitOpen, cb.state)
+
+	time.Sleep(2 * time.Millisecond)
+	require.True(t, cb.allow(), "should allow a probe once open_duration elapses")
+	require.Equal(t, circuitHalfOpen, cb.state)
+
+	cb.recordResult(true)
+	require.Equal(t, circuitClosed, cb.state)
+}
+
+func TestGetOrCreateCircuitBreaker_RebuildsOnConfigLabelChange(t *testing.T) {
+	circuitBreakersMu.Lock()
+	circuitBreakers = map[string]*circuitBreaker{}
+	circuitBreakersMu.Unlock()
+
+	cb1 := getOrCreateCircuitBreaker("ds-1", map[string]string{"cb_min_requests": "4"})
+	cb2 := getOrCreateCircuitBreaker("ds-1", map[string]string{"cb_min_requests": "4"})
+	require.Same(t, cb1, cb2, "unchanged config labels should reuse the cached breaker")
+
+	cb3 := getOrCreateCircuitBreaker("ds-1", map[string]string{"cb_min_requests": "20"})
+	require.NotSame(t, cb1, cb3, "a retuned cb_* label should rebuild the breaker instead of being ignored until restart")
+}
+
+func TestGetOrCreateCircuitBreaker_EmptyDatasourceUIDIsNeverShared(t *testing.T) {
+	cb1 := getOrCreateCircuitBreaker("", map[string]string{"cb_min_requests": "4"})
+	cb2 := getOrCreateCircuitBreaker("", map[string]string{"cb_min_requests": "4"})
+	require.NotSame(t, cb1, cb2, "callers without a datasource_uid must not share one breaker instance")
+}
+
+func TestResetCircuitBreakers_ForcesRebuild(t *testing.T) {
+	cb1 := getOrCreateCircuitBreaker("ds-reset", map[string]string{"cb_min_requests": "4"})
+	ResetCircuitBreakers()
+	cb2 := getOrCreateCircuitBreaker("ds-reset", map[string]string{"cb_min_requests": "4"})
+	require.NotSame(t, cb1, cb2)
+}
+
+func TestCircuitBreaker_HalfOpenFailureReopensWithBackoff(t *testing.T) {
+	cb := newTestCircuitBreaker(map[string]string{
+		"cb_min_requests":           "2",
+		"cb_failure_threshold":      "0.5",
+		"cb_open_duration":          "1ms",
+		"cb_half_open_max_requests": "1",
+	})
+
+	cb.recordResult(false)
+	cb.recordResult(false)
+	firstBackoff := cb.backoff
+
+	time.Sleep(2 * time.Millisecond)
+	require.True(t, cb.allow())
+	cb.recordResult(false)
+
+	require.Equal(t, circuitOpen, cb.state)
+	require.Greater(t, cb.backoff, firstBackoff, "a failed probe should back off further than the initial open duration")
+}
@@ -0,0 +1,13 @@
+package httpclientprovider
+
+import "time"
+
+// secondsToDuration converts a DataProxy* timeout setting - a plain number of
+// seconds on *setting.Cfg - to a time.Duration.
+//
+// setting.Cfg's DataProxy* fields are integers, so these settings are whole
+// seconds only; Go duration strings such as "30s" or "250ms" are not
+// accepted.
+func secondsToDuration(seconds int) time.Duration {
+	return time.Duration(seconds) * time.Second
+}
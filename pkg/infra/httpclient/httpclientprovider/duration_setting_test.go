@@ -0,0 +1,13 @@
+package httpclientprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecondsToDuration(t *testing.T) {
+	require.Equal(t, 30*time.Second, secondsToDuration(30))
+	require.Equal(t, time.Duration(0), secondsToDuration(0))
+}
@@ -1,9 +1,10 @@
 package httpclientprovider
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
-	"time"
+	"sync/atomic"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
@@ -17,8 +18,64 @@ import (
 
 var newProviderFunc = sdkhttpclient.NewProvider
 
+// Provider wraps the plugin SDK's http client provider. The middleware chain
+// it builds - which of SigV4Middleware/RetryMiddleware are present, the
+// DataProxy* timeout defaults they fall back to - is decided from a
+// *setting.Cfg snapshot, and ReloadDefaults lets that snapshot be refreshed
+// (e.g. on SIGHUP) without restarting Grafana.
+type Provider struct {
+	tracer  tracing.Tracer
+	current atomic.Pointer[sdkhttpclient.Provider]
+}
+
 // New creates a new HTTP client provider with pre-configured middlewares.
-func New(cfg *setting.Cfg, tracer tracing.Tracer) *sdkhttpclient.Provider {
+func New(cfg *setting.Cfg, tracer tracing.Tracer) *Provider {
+	p := &Provider{tracer: tracer}
+	p.current.Store(buildProvider(cfg, tracer))
+	return p
+}
+
+// ReloadDefaults rebuilds the middleware chain and ConfigureTransport hook
+// from cfg - re-evaluating cfg.DataProxyRetryEnabled, cfg.SigV4AuthEnabled
+// and the DataProxy* timeout/pool settings, all of which were otherwise only
+// ever read once in New() - and atomically swaps it in. It also discards any
+// cached circuit breakers, so a per-datasource cb_* label retune doesn't
+// wait on this reload to take effect either (getOrCreateCircuitBreaker
+// already rebuilds on its own once labels change). Call ReloadDefaults after
+// a SIGHUP-driven config reload so tuning these takes effect without
+// restarting Grafana.
+//
+// In-flight requests keep using whichever *http.Client they already
+// obtained; only calls to p.New/p.GetTransport/p.GetTLSConfig made after
+// ReloadDefaults returns observe the new chain.
+func (p *Provider) ReloadDefaults(cfg *setting.Cfg) {
+	p.current.Store(buildProvider(cfg, p.tracer))
+	ResetCircuitBreakers()
+}
+
+// New builds a *http.Client for opts using the middleware chain currently in
+// effect.
+func (p *Provider) New(opts ...sdkhttpclient.Options) (*http.Client, error) {
+	return p.current.Load().New(opts...)
+}
+
+// GetTransport returns a http.RoundTripper for opts using the middleware
+// chain currently in effect.
+func (p *Provider) GetTransport(opts ...sdkhttpclient.Options) (http.RoundTripper, error) {
+	return p.current.Load().GetTransport(opts...)
+}
+
+// GetTLSConfig returns a *tls.Config for opts using the middleware chain
+// currently in effect.
+func (p *Provider) GetTLSConfig(opts ...sdkhttpclient.Options) (*tls.Config, error) {
+	return p.current.Load().GetTLSConfig(opts...)
+}
+
+// buildProvider assembles a *sdkhttpclient.Provider from cfg: the
+// middlewares, whether SigV4Middleware/RetryMiddleware are included, the
+// ConfigureTransport hook, and sdkhttpclient.DefaultTimeoutOptions are all
+// derived here so New and ReloadDefaults stay in lockstep.
+func buildProvider(cfg *setting.Cfg, tracer tracing.Tracer) *sdkhttpclient.Provider {
 	logger := log.New("httpclient")
 	userAgent := fmt.Sprintf("Grafana/%s", cfg.BuildVersion)
 
@@ -30,17 +87,25 @@ func New(cfg *setting.Cfg, tracer tracing.Tracer) *sdkhttpclient.Provider {
 		sdkhttpclient.BasicAuthenticationMiddleware(),
 		sdkhttpclient.CustomHeadersMiddleware(),
 		ResponseLimitMiddleware(cfg.ResponseLimit),
+		CircuitBreakerMiddleware(),
+		TimeoutMiddleware(logger),
 	}
 
 	if cfg.SigV4AuthEnabled {
 		middlewares = append(middlewares, SigV4Middleware(cfg.SigV4VerboseLogging))
 	}
 
+	if cfg.DataProxyRetryEnabled {
+		middlewares = append(middlewares, RetryMiddleware(DefaultRetryPolicy))
+	}
+
 	setDefaultTimeoutOptions(cfg)
 
 	return newProviderFunc(sdkhttpclient.ProviderOptions{
 		Middlewares: middlewares,
 		ConfigureTransport: func(opts sdkhttpclient.Options, transport *http.Transport) {
+			applyTimeoutOverrides(logger, opts, transport)
+
 			datasourceName, exists := opts.Labels["datasource_name"]
 			if !exists {
 				return
@@ -51,6 +116,7 @@ func New(cfg *setting.Cfg, tracer tracing.Tracer) *sdkhttpclient.Provider {
 				return
 			}
 			newConntrackRoundTripper(datasourceLabelName, transport)
+			applyMaxConnLifetime(cfg.DataProxyMaxConnAge, transport)
 		},
 	})
 }
@@ -93,16 +159,20 @@ func httpLoggerMiddleware() httpclient.Middleware {
 // setDefaultTimeoutOptions overrides the default timeout options for the SDK.
 //
 // Note: Not optimal changing global state, but hard to not do in this case.
+//
+// cfg's DataProxy* timeout fields are plain integer seconds, matching
+// setting.Cfg's actual field types - see secondsToDuration's doc comment for
+// why these aren't Go duration strings.
 func setDefaultTimeoutOptions(cfg *setting.Cfg) {
 	sdkhttpclient.DefaultTimeoutOptions = sdkhttpclient.TimeoutOptions{
-		Timeout:               time.Duration(cfg.DataProxyTimeout) * time.Second,
-		DialTimeout:           time.Duration(cfg.DataProxyDialTimeout) * time.Second,
-		KeepAlive:             time.Duration(cfg.DataProxyKeepAlive) * time.Second,
-		TLSHandshakeTimeout:   time.Duration(cfg.DataProxyTLSHandshakeTimeout) * time.Second,
-		ExpectContinueTimeout: time.Duration(cfg.DataProxyExpectContinueTimeout) * time.Second,
+		Timeout:               secondsToDuration(cfg.DataProxyTimeout),
+		DialTimeout:           secondsToDuration(cfg.DataProxyDialTimeout),
+		KeepAlive:             secondsToDuration(cfg.DataProxyKeepAlive),
+		TLSHandshakeTimeout:   secondsToDuration(cfg.DataProxyTLSHandshakeTimeout),
+		ExpectContinueTimeout: secondsToDuration(cfg.DataProxyExpectContinueTimeout),
 		MaxConnsPerHost:       cfg.DataProxyMaxConnsPerHost,
 		MaxIdleConns:          cfg.DataProxyMaxIdleConns,
 		MaxIdleConnsPerHost:   cfg.DataProxyMaxIdleConns,
-		IdleConnTimeout:       time.Duration(cfg.DataProxyIdleConnTimeout) * time.Second,
+		IdleConnTimeout:       secondsToDuration(cfg.DataProxyIdleConnTimeout),
 	}
 }
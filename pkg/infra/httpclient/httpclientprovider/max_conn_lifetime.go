@@ -0,0 +1,63 @@
+package httpclientprovider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errConnExpired is returned by expiringConn once its maximum lifetime has
+// elapsed, causing http.Transport to discard the connection and dial a new
+// one on the next request instead of reusing potentially stale L4 state.
+var errConnExpired = errors.New("httpclientprovider: connection exceeded max lifetime")
+
+// expiringConn wraps a net.Conn and fails Read/Write once it has been alive
+// longer than maxAge, forcing http.Transport to close it and dial again.
+type expiringConn struct {
+	net.Conn
+	dialTime time.Time
+	maxAge   time.Duration
+}
+
+func (c *expiringConn) expired() bool {
+	return time.Since(c.dialTime) > c.maxAge
+}
+
+func (c *expiringConn) Read(b []byte) (int, error) {
+	if c.expired() {
+		return 0, errConnExpired
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *expiringConn) Write(b []byte) (int, error) {
+	if c.expired() {
+		return 0, errConnExpired
+	}
+	return c.Conn.Write(b)
+}
+
+// applyMaxConnLifetime wraps transport.DialContext so every dialled
+// connection is torn down after maxAge, bounding Grafana's exposure to stale
+// L4 state behind load balancers and proxies without disabling keep-alives.
+//
+// It must run after the dialer has already been wrapped for conntrack
+// instrumentation, so the lifetime check is the last thing standing between
+// http.Transport and the raw connection.
+func applyMaxConnLifetime(maxAge time.Duration, transport *http.Transport) {
+	if maxAge <= 0 {
+		return
+	}
+
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return &expiringConn{Conn: conn, dialTime: time.Now(), maxAge: maxAge}, nil
+	}
+}
@@ -0,0 +1,70 @@
+package httpclientprovider
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConn struct {
+	net.Conn
+}
+
+func (fakeConn) Read([]byte) (int, error)  { return 0, nil }
+func (fakeConn) Write([]byte) (int, error) { return 0, nil }
+
+func TestExpiringConn_FailsReadWriteOnceMaxAgeElapses(t *testing.T) {
+	conn := &expiringConn{Conn: fakeConn{}, dialTime: time.Now().Add(-time.Hour), maxAge: time.Minute}
+
+	_, err := conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errConnExpired)
+
+	_, err = conn.Write(make([]byte, 1))
+	require.ErrorIs(t, err, errConnExpired)
+}
+
+func TestExpiringConn_PassesThroughBeforeMaxAgeElapses(t *testing.T) {
+	conn := &expiringConn{Conn: fakeConn{}, dialTime: time.Now(), maxAge: time.Hour}
+
+	_, err := conn.Read(make([]byte, 1))
+	require.NoError(t, err)
+
+	_, err = conn.Write(make([]byte, 1))
+	require.NoError(t, err)
+}
+
+func TestApplyMaxConnLifetime_NoopWhenDisabled(t *testing.T) {
+	dialCalls := 0
+	transport := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialCalls++
+		return fakeConn{}, nil
+	}}
+
+	applyMaxConnLifetime(0, transport)
+
+	conn, err := transport.DialContext(context.Background(), "tcp", "example.com:80")
+	require.NoError(t, err)
+	_, ok := conn.(*expiringConn)
+	require.False(t, ok, "DialContext shouldn't be wrapped when maxAge is disabled")
+	require.Equal(t, 1, dialCalls)
+}
+
+func TestApplyMaxConnLifetime_WrapsDialedConnWithDeadline(t *testing.T) {
+	transport := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return fakeConn{}, nil
+	}}
+
+	applyMaxConnLifetime(time.Minute, transport)
+
+	conn, err := transport.DialContext(context.Background(), "tcp", "example.com:80")
+	require.NoError(t, err)
+
+	ec, ok := conn.(*expiringConn)
+	require.True(t, ok)
+	require.Equal(t, time.Minute, ec.maxAge)
+	require.False(t, ec.expired())
+}
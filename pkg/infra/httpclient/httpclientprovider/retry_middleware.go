@@ -0,0 +1,200 @@
+package httpclientprovider
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+// RetryPolicy controls RetryMiddleware's attempt count and backoff curve.
+type RetryPolicy struct {
+	MaxAttempts        int
+	InitialBackoff     time.Duration
+	MaxBackoff         time.Duration
+	Multiplier         float64
+	JitterFraction     float64
+	MaxBodyBufferBytes int64
+}
+
+// DefaultRetryPolicy is used wherever callers don't need to tune the curve
+// themselves.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:        3,
+	InitialBackoff:     100 * time.Millisecond,
+	MaxBackoff:         2 * time.Second,
+	Multiplier:         2.0,
+	JitterFraction:     0.2,
+	MaxBodyBufferBytes: 1 << 20, // 1MiB
+}
+
+// RetryMiddleware transparently retries idempotent requests - GET, HEAD,
+// OPTIONS, PUT, DELETE, plus POST when the datasource opts in via
+// opts.Labels["retry_post"]="true" - on connection errors and 429/502/503/504
+// responses. A Retry-After response header is honored when present, capped by
+// policy.MaxBackoff; otherwise the middleware backs off with jittered
+// exponential delay.
+func RetryMiddleware(policy RetryPolicy) httpclient.Middleware {
+	return httpclient.NamedMiddlewareFunc("retry", func(opts httpclient.Options, next http.RoundTripper) http.RoundTripper {
+		retryPost := opts.Labels["retry_post"] == "true"
+
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !isRetryableMethod(req.Method, retryPost) {
+				return next.RoundTrip(req)
+			}
+
+			rewind, err := bufferBody(req, policy.MaxBodyBufferBytes)
+			if err != nil {
+				// req.Body was partially drained by the failed read, so it
+				// can no longer be sent intact - abort instead of letting a
+				// truncated body go out.
+				return nil, err
+			}
+
+			// A body that can't be rewound can only be sent once; a request
+			// without a body can be resent as is.
+			canRetry := rewind != nil || req.Body == nil || req.Body == http.NoBody
+
+			var resp *http.Response
+			for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+				if attempt > 0 && rewind != nil {
+					body, rewindErr := rewind()
+					if rewindErr != nil {
+						return resp, rewindErr
+					}
+					req.Body = body
+				}
+
+				resp, err = next.RoundTrip(req)
+				if !canRetry || !shouldRetry(resp, err) || attempt == policy.MaxAttempts-1 {
+					return resp, err
+				}
+
+				wait := retryWait(policy, attempt, resp)
+				if resp != nil && resp.Body != nil {
+					resp.Body.Close()
+				}
+
+				select {
+				case <-req.Context().Done():
+					return resp, req.Context().Err()
+				case <-time.After(wait):
+				}
+			}
+
+			return resp, err
+		})
+	})
+}
+
+func isRetryableMethod(method string, retryPost bool) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return retryPost
+	default:
+		return false
+	}
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// bufferBody returns a function producing a fresh, rewound copy of req.Body
+// for each retry attempt - req.GetBody itself when the request already knows
+// how to rewind itself - or nil if no rewind is needed or available: either
+// the request has no body, or it's larger than maxBytes and not worth
+// buffering. In the last case req.Body is restored in full so the single
+// attempt still goes out uncorrupted; retries are simply skipped for it.
+func bufferBody(req *http.Request, maxBytes int64) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxBytes {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+		return nil, nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, nil
+}
+
+func retryWait(policy RetryPolicy, attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfterDelay(resp.Header.Get("Retry-After")); ok {
+			if d > policy.MaxBackoff {
+				d = policy.MaxBackoff
+			}
+			return d
+		}
+	}
+
+	backoff := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(attempt))
+	if backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+
+	jitter := backoff * policy.JitterFraction * (rand.Float64() - 0.5)
+	sleep := time.Duration(backoff + jitter)
+	if sleep < 0 {
+		sleep = 0
+	}
+
+	return sleep
+}
+
+// retryAfterDelay parses a Retry-After header value, which per RFC 7231 is
+// either delta-seconds or an HTTP-date.
+func retryAfterDelay(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,122 @@
+package httpclientprovider
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableMethod(t *testing.T) {
+	require.True(t, isRetryableMethod(http.MethodGet, false))
+	require.True(t, isRetryableMethod(http.MethodPut, false))
+	require.True(t, isRetryableMethod(http.MethodDelete, false))
+	require.False(t, isRetryableMethod(http.MethodPost, false))
+	require.True(t, isRetryableMethod(http.MethodPost, true))
+	require.False(t, isRetryableMethod(http.MethodPatch, true))
+}
+
+func TestShouldRetry(t *testing.T) {
+	require.True(t, shouldRetry(nil, errors.New("boom")))
+	require.True(t, shouldRetry(&http.Response{StatusCode: http.StatusBadGateway}, nil))
+	require.True(t, shouldRetry(&http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+	require.False(t, shouldRetry(&http.Response{StatusCode: http.StatusOK}, nil))
+	require.False(t, shouldRetry(&http.Response{StatusCode: http.StatusNotFound}, nil))
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	d, ok := retryAfterDelay("5")
+	require.True(t, ok)
+	require.Equal(t, 5*time.Second, d)
+
+	_, ok = retryAfterDelay("-1")
+	require.False(t, ok)
+
+	_, ok = retryAfterDelay("")
+	require.False(t, ok)
+
+	d, ok = retryAfterDelay(time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat))
+	require.True(t, ok)
+	require.Greater(t, d, time.Duration(0))
+}
+
+func TestRetryWait_HonorsRetryAfterCappedByMaxBackoff(t *testing.T) {
+	policy := DefaultRetryPolicy
+	policy.MaxBackoff = time.Second
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"10"}}}
+	wait := retryWait(policy, 0, resp)
+	require.Equal(t, policy.MaxBackoff, wait)
+}
+
+func TestRetryWait_ExponentialWithJitterBounds(t *testing.T) {
+	policy := DefaultRetryPolicy
+
+	for attempt := 0; attempt < 5; attempt++ {
+		wait := retryWait(policy, attempt, nil)
+		require.GreaterOrEqual(t, wait, time.Duration(0))
+		require.LessOrEqual(t, wait, policy.MaxBackoff+policy.MaxBackoff/2)
+	}
+}
+
+func TestBufferBody_NilBodyNeedsNoRewind(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	rewind, err := bufferBody(req, 1024)
+	require.NoError(t, err)
+	require.Nil(t, rewind)
+}
+
+func TestBufferBody_SmallBodyIsReplayable(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", io.NopCloser(strings.NewReader("payload")))
+	require.NoError(t, err)
+	req.GetBody = nil
+
+	rewind, err := bufferBody(req, 1024)
+	require.NoError(t, err)
+	require.NotNil(t, rewind)
+
+	first, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(first))
+
+	rc, err := rewind()
+	require.NoError(t, err)
+	second, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(second))
+}
+
+func TestBufferBody_OversizedBodySkipsRetryButStaysIntact(t *testing.T) {
+	payload := strings.Repeat("a", 16)
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", io.NopCloser(strings.NewReader(payload)))
+	require.NoError(t, err)
+	req.GetBody = nil
+
+	rewind, err := bufferBody(req, 4)
+	require.NoError(t, err)
+	require.Nil(t, rewind)
+
+	got, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, payload, string(got))
+}
+
+type erroringReader struct{}
+
+func (erroringReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestBufferBody_ReadErrorIsReturned(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", io.NopCloser(erroringReader{}))
+	require.NoError(t, err)
+	req.GetBody = nil
+
+	rewind, err := bufferBody(req, 1024)
+	require.Error(t, err)
+	require.Nil(t, rewind)
+}
@@ -0,0 +1,131 @@
+package httpclientprovider
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// Labels a datasource can set on sdkhttpclient.Options to override Grafana's
+// global DataProxy* timeout and connection pool defaults for itself alone.
+const (
+	labelHTTPTimeout               = "http_timeout"
+	labelHTTPDialTimeout           = "http_dial_timeout"
+	labelHTTPKeepAlive             = "http_keep_alive"
+	labelHTTPTLSHandshakeTimeout   = "http_tls_handshake_timeout"
+	labelHTTPExpectContinueTimeout = "http_expect_continue_timeout"
+	labelHTTPIdleConnTimeout       = "http_idle_conn_timeout"
+	labelHTTPMaxConnsPerHost       = "http_max_conns_per_host"
+	labelHTTPMaxIdleConns          = "http_max_idle_conns"
+	labelHTTPMaxIdleConnsPerHost   = "http_max_idle_conns_per_host"
+)
+
+// TimeoutMiddleware enforces the per-datasource overall request timeout read
+// from opts.Labels["http_timeout"], falling back to
+// sdkhttpclient.DefaultTimeoutOptions.Timeout. It exists alongside
+// applyTimeoutOverrides rather than folded into it because Timeout has no
+// http.Transport field to set - it's normally enforced by http.Client.Timeout,
+// which ConfigureTransport has no access to - so this wraps the RoundTripper
+// with a context deadline instead.
+func TimeoutMiddleware(logger log.Logger) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("datasource-timeout", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		datasourceName := opts.Labels["datasource_name"]
+		timeout := durationLabel(logger, datasourceName, opts.Labels, labelHTTPTimeout, sdkhttpclient.DefaultTimeoutOptions.Timeout)
+		if timeout <= 0 {
+			return next
+		}
+
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+			resp, err := next.RoundTrip(req.WithContext(ctx))
+			if err != nil {
+				cancel()
+				return resp, err
+			}
+
+			// The deadline has to survive until the body is fully read, not
+			// just until headers arrive, so defer cancellation to Close -
+			// the same trick net/http uses internally for http.Client.Timeout.
+			resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		})
+	})
+}
+
+// cancelOnCloseBody cancels its context once the response body is closed, so
+// a context.WithTimeout started by TimeoutMiddleware is released after the
+// caller finishes (or abandons) reading the response instead of leaking
+// until the deadline itself elapses.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// applyTimeoutOverrides reads per-datasource transport-level timeout and
+// connection pool overrides from opts.Labels and applies them to transport,
+// falling back to the global sdkhttpclient.DefaultTimeoutOptions when a
+// label is missing or can't be parsed. It must run before the dialer gets
+// wrapped for conntrack instrumentation, since it replaces
+// transport.DialContext wholesale.
+//
+// The overall per-request Timeout is handled separately by
+// TimeoutMiddleware, since http.Transport has no field for it.
+func applyTimeoutOverrides(logger log.Logger, opts sdkhttpclient.Options, transport *http.Transport) {
+	datasourceName := opts.Labels["datasource_name"]
+	defaults := sdkhttpclient.DefaultTimeoutOptions
+
+	transport.DialContext = (&net.Dialer{
+		Timeout:   durationLabel(logger, datasourceName, opts.Labels, labelHTTPDialTimeout, defaults.DialTimeout),
+		KeepAlive: durationLabel(logger, datasourceName, opts.Labels, labelHTTPKeepAlive, defaults.KeepAlive),
+	}).DialContext
+
+	transport.TLSHandshakeTimeout = durationLabel(logger, datasourceName, opts.Labels, labelHTTPTLSHandshakeTimeout, defaults.TLSHandshakeTimeout)
+	transport.ExpectContinueTimeout = durationLabel(logger, datasourceName, opts.Labels, labelHTTPExpectContinueTimeout, defaults.ExpectContinueTimeout)
+	transport.IdleConnTimeout = durationLabel(logger, datasourceName, opts.Labels, labelHTTPIdleConnTimeout, defaults.IdleConnTimeout)
+
+	transport.MaxConnsPerHost = intLabel(logger, datasourceName, opts.Labels, labelHTTPMaxConnsPerHost, defaults.MaxConnsPerHost)
+	transport.MaxIdleConns = intLabel(logger, datasourceName, opts.Labels, labelHTTPMaxIdleConns, defaults.MaxIdleConns)
+	transport.MaxIdleConnsPerHost = intLabel(logger, datasourceName, opts.Labels, labelHTTPMaxIdleConnsPerHost, defaults.MaxIdleConnsPerHost)
+}
+
+func durationLabel(logger log.Logger, datasourceName string, labels map[string]string, key string, fallback time.Duration) time.Duration {
+	value, exists := labels[key]
+	if !exists {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("Failed to parse duration label, using default", "datasource", datasourceName, "label", key, "value", value, "error", err)
+		return fallback
+	}
+
+	return d
+}
+
+func intLabel(logger log.Logger, datasourceName string, labels map[string]string, key string, fallback int) int {
+	value, exists := labels[key]
+	if !exists {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warn("Failed to parse integer label, using default", "datasource", datasourceName, "label", key, "value", value, "error", err)
+		return fallback
+	}
+
+	return n
+}
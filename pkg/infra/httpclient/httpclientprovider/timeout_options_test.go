@@ -0,0 +1,76 @@
+package httpclientprovider
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = log.New("httpclientprovider.test")
+
+func TestDurationLabel(t *testing.T) {
+	labels := map[string]string{"http_dial_timeout": "5s", "http_keep_alive": "not-a-duration"}
+
+	require.Equal(t, 5*time.Second, durationLabel(testLogger, "ds", labels, "http_dial_timeout", time.Second))
+	require.Equal(t, time.Second, durationLabel(testLogger, "ds", labels, "http_keep_alive", time.Second), "malformed label should fall back to the default")
+	require.Equal(t, time.Second, durationLabel(testLogger, "ds", labels, "http_missing", time.Second), "missing label should fall back to the default")
+}
+
+func TestIntLabel(t *testing.T) {
+	labels := map[string]string{"http_max_conns_per_host": "42", "http_max_idle_conns": "not-a-number"}
+
+	require.Equal(t, 42, intLabel(testLogger, "ds", labels, "http_max_conns_per_host", 1))
+	require.Equal(t, 1, intLabel(testLogger, "ds", labels, "http_max_idle_conns", 1), "malformed label should fall back to the default")
+	require.Equal(t, 1, intLabel(testLogger, "ds", labels, "http_missing", 1), "missing label should fall back to the default")
+}
+
+func TestApplyTimeoutOverrides_UsesLabelsOverDefaults(t *testing.T) {
+	opts := sdkhttpclient.Options{Labels: map[string]string{
+		"datasource_name":              "influx",
+		"http_tls_handshake_timeout":   "7s",
+		"http_idle_conn_timeout":       "9s",
+		"http_max_conns_per_host":      "11",
+		"http_max_idle_conns_per_host": "13",
+	}}
+
+	transport := &http.Transport{}
+	applyTimeoutOverrides(testLogger, opts, transport)
+
+	require.Equal(t, 7*time.Second, transport.TLSHandshakeTimeout)
+	require.Equal(t, 9*time.Second, transport.IdleConnTimeout)
+	require.Equal(t, 11, transport.MaxConnsPerHost)
+	require.Equal(t, 13, transport.MaxIdleConnsPerHost)
+	require.NotNil(t, transport.DialContext)
+}
+
+func TestApplyTimeoutOverrides_FallsBackToGlobalDefaults(t *testing.T) {
+	defaults := sdkhttpclient.DefaultTimeoutOptions
+	defer func() { sdkhttpclient.DefaultTimeoutOptions = defaults }()
+	sdkhttpclient.DefaultTimeoutOptions = sdkhttpclient.TimeoutOptions{
+		TLSHandshakeTimeout: 3 * time.Second,
+		MaxConnsPerHost:     100,
+	}
+
+	transport := &http.Transport{}
+	applyTimeoutOverrides(testLogger, sdkhttpclient.Options{}, transport)
+
+	require.Equal(t, 3*time.Second, transport.TLSHandshakeTimeout)
+	require.Equal(t, 100, transport.MaxConnsPerHost)
+}
+
+func TestCancelOnCloseBody_ClosingCancelsContext(t *testing.T) {
+	var cancelled bool
+	body := &cancelOnCloseBody{
+		ReadCloser: io.NopCloser(strings.NewReader("x")),
+		cancel:     func() { cancelled = true },
+	}
+
+	require.NoError(t, body.Close())
+	require.True(t, cancelled)
+}